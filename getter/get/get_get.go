@@ -8,7 +8,7 @@ import (
 	"github.com/dave/services/getter/gettermsg"
 )
 
-func (g *Getter) download(ctx context.Context, path string, parent *Package, stk *ImportStack, update bool, insecure, single bool) error {
+func (g *Getter) download(ctx context.Context, path string, parent *Package, stk *ImportStack, update bool, insecure, single, tests bool) error {
 	load1 := func(path string, useVendor bool) *Package {
 		if parent == nil {
 			return g.LoadImport(ctx, path, "/", nil, stk, false)
@@ -36,9 +36,7 @@ func (g *Getter) download(ctx context.Context, path string, parent *Package, stk
 	}
 
 	// Only process each package once.
-	// (Unless we're fetching test dependencies for this package,
-	// in which case we want to process it again.)
-	if g.downloadCache[path] {
+	if g.shouldSkipDownload(path, update, tests) {
 		return nil
 	}
 	if !single {
@@ -81,7 +79,8 @@ func (g *Getter) download(ctx context.Context, path string, parent *Package, stk
 		// so it can be stored as a hint by gitcache
 		if root, _ := g.vcsFromDir(p.Dir, p.Internal.Build.SrcRoot); root != nil {
 			// ignore the error
-			g.repoPackages[p.ImportPath] = root
+			rev, _ := g.resolveRev(ctx, root)
+			g.repoPackages[p.ImportPath] = &repoPackage{root: root, rev: rev}
 		}
 	}
 
@@ -122,15 +121,57 @@ func (g *Getter) download(ctx context.Context, path string, parent *Package, stk
 			if i >= len(p.Imports) {
 				path = g.VendoredImportPath(p, path)
 			}
-			if err := g.download(ctx, path, p, stk, update, insecure, false); err != nil {
+			if err := g.download(ctx, path, p, stk, update, insecure, false, tests); err != nil {
 				return err
 			}
 		}
+
+		// -t equivalent: also pull in whatever the package's own tests need,
+		// which don't show up in p.Imports above.
+		if tests {
+			var testImports []string
+			testImports = append(testImports, p.Internal.Build.TestImports...)
+			testImports = append(testImports, p.Internal.Build.XTestImports...)
+			for _, path := range testImports {
+				if path == "C" {
+					continue
+				}
+				path = g.VendoredImportPath(p, path)
+				if err := g.download(ctx, path, p, stk, update, insecure, false, tests); err != nil {
+					return err
+				}
+			}
+		}
 	}
 
 	return nil
 }
 
+// shouldSkipDownload reports whether download should return early for path
+// without calling downloadPackage again. A path already in g.downloadCache
+// is normally done, but tests and update both need to revisit it anyway:
+// tests, because test-only dependencies aren't pulled in on the first pass,
+// and update, because a second Get(..., update=true, ...) for the same path
+// must still reach downloadPackage to advance the working tree - caching on
+// path alone would make every update after the first a no-op.
+func (g *Getter) shouldSkipDownload(path string, update, tests bool) bool {
+	return g.downloadCache[path] && !tests && !update
+}
+
+// packagesUnderRoot returns the import paths previously recorded in
+// g.repoPackages whose repo root matches root, so that an -u refresh can
+// invalidate every package cache entry the updated checkout might affect,
+// not just the one the caller asked for.
+func (g *Getter) packagesUnderRoot(root *repoRoot) []string {
+	var paths []string
+	for path, r := range g.repoPackages {
+		if r.root.dir == root.dir {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
 // downloadPackage runs the create or download command
 // to make the first copy of or update a copy of the given package.
 func (g *Getter) downloadPackage(ctx context.Context, p *Package, update bool, insecure bool) error {
@@ -168,7 +209,7 @@ func (g *Getter) downloadPackage(ctx context.Context, p *Package, update bool, i
 		if filepath.Clean(list[0]) == filepath.Clean(g.buildContext.GOROOT) {
 			return fmt.Errorf("cannot download, $GOPATH must not be set to $GOROOT. For more details see: 'go help gopath'")
 		}
-		if _, err := g.session.GoPath().Stat(filepath.Join(list[0], "src/cmd/go/alldocs.go")); err == nil {
+		if _, err := g.GoPath().Stat(filepath.Join(list[0], "src/cmd/go/alldocs.go")); err == nil {
 			return fmt.Errorf("cannot download, %s is a GOROOT, not a GOPATH. For more details see: 'go help gopath'", list[0])
 		}
 		p.Internal.Build.Root = list[0]
@@ -182,7 +223,11 @@ func (g *Getter) downloadPackage(ctx context.Context, p *Package, update bool, i
 		return fmt.Errorf("path disagreement, calculated %s, expected %s", dir, root.dir)
 	}
 
-	g.repoPackages[p.ImportPath] = root
+	rev, err := g.resolveRev(ctx, root)
+	if err != nil {
+		return err
+	}
+	g.repoPackages[p.ImportPath] = &repoPackage{root: root, rev: rev}
 
 	// If we've considered this repository already, don't do it again.
 	if _, ok := g.downloadRootCache[root.dir]; ok {
@@ -191,7 +236,7 @@ func (g *Getter) downloadPackage(ctx context.Context, p *Package, update bool, i
 	g.downloadRootCache[root.dir] = root
 
 	if !root.exists {
-		fs := g.session.GoPath()
+		fs := g.GoPath()
 
 		// Root does not exist. Prepare to checkout new copy.
 		// Some version control tools require the target directory not to exist.
@@ -209,20 +254,27 @@ func (g *Getter) downloadPackage(ctx context.Context, p *Package, update bool, i
 			g.send(gettermsg.Downloading{Message: root.root})
 		}
 
-		if err = root.create(ctx, fs); err != nil {
+		if err = root.create(ctx, fs, rev); err != nil {
 			return err
 		}
+		root.exists = true
 	} else {
 		// Root does exist; download incremental updates.
-		panic("root exists")
-
 		if g.send != nil {
 			g.send(gettermsg.Downloading{Message: root.root})
 		}
 
-		if err = root.download(ctx); err != nil {
+		if err = root.download(ctx, rev); err != nil {
 			return err
 		}
+
+		// The working tree has moved on; the package cache entries we built
+		// from the old checkout are stale. download (our caller) clears
+		// g.packageCache for the arg path once this returns, but root.dir
+		// may cover other packages too (e.g. when -u is applied to a
+		// sub-package of a multi-package repo), so clear everything under
+		// the repo root as well.
+		g.ClearPackageCachePartial(g.packagesUnderRoot(root))
 	}
 
 	//if cfg.BuildN {
@@ -0,0 +1,35 @@
+package get
+
+import "testing"
+
+// TestShouldSkipDownloadGatesOnUpdate exercises the bug fixed alongside the
+// incremental-update work in download: a path already in g.downloadCache
+// must still be revisited when the caller passes update=true, or a second
+// Get(ctx, path, ..., update=true, ...) on the same Getter would short-
+// circuit before ever reaching downloadPackage/root.download and the
+// working tree could never advance past the first Get.
+//
+// download itself can't be driven end-to-end here - it starts by calling
+// g.LoadImport, promoted from the *session.Session this package embeds but
+// doesn't vendor - so this exercises the extracted gating decision
+// directly instead.
+func TestShouldSkipDownloadGatesOnUpdate(t *testing.T) {
+	g := &Getter{downloadCache: map[string]bool{}}
+	path := "example.com/foo"
+
+	if g.shouldSkipDownload(path, false, false) {
+		t.Fatal("a path not yet in downloadCache must not be skipped")
+	}
+
+	g.downloadCache[path] = true
+
+	if !g.shouldSkipDownload(path, false, false) {
+		t.Fatal("a plain repeat Get for an already-downloaded path should be skipped")
+	}
+	if g.shouldSkipDownload(path, true, false) {
+		t.Fatal("update=true must still revisit an already-downloaded path, or a second Get(update=true) can never advance the working tree")
+	}
+	if g.shouldSkipDownload(path, false, true) {
+		t.Fatal("tests=true must still revisit an already-downloaded path to pull in its test imports")
+	}
+}
@@ -0,0 +1,424 @@
+package get
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Mode selects how Getter resolves and fetches a package: either as a plain
+// VCS checkout into GOPATH (the historical behaviour), or as a module
+// fetched from a GOPROXY-protocol endpoint.
+type Mode int
+
+const (
+	// ModeGOPATH resolves and checks out packages directly from their VCS,
+	// the way this package has always worked.
+	ModeGOPATH Mode = iota
+	// ModeModule resolves packages as modules: a module path and version are
+	// worked out, the module zip is fetched from a proxy and unpacked into
+	// session.GoPath()'s pkg/mod cache.
+	ModeModule
+)
+
+// Module identifies a single entry in the resolved build list.
+type Module struct {
+	Path    string
+	Version string
+}
+
+// DefaultProxyList is used by a Getter that hasn't had SetProxyList called.
+var DefaultProxyList = []string{"https://proxy.golang.org"}
+
+// SetProxyList overrides the list of GOPROXY-protocol endpoints that are
+// tried, in order, when resolving and fetching modules.
+func (g *Getter) SetProxyList(proxies []string) {
+	g.proxyList = proxies
+}
+
+// SetGoMod supplies the contents of a go.mod file whose require directives
+// pin module versions. When a pinned version is available it is preferred
+// over asking a proxy for $latest.
+func (g *Getter) SetGoMod(data []byte) {
+	g.goMod = parseGoModRequires(data)
+}
+
+// BuildList returns the modules resolved so far in module mode, so that the
+// caller can build with GOFLAGS=-mod=mod against a consistent set of
+// versions.
+func (g *Getter) BuildList() []Module {
+	list := make([]Module, 0, len(g.buildList))
+	for path, version := range g.buildList {
+		list = append(list, Module{Path: path, Version: version})
+	}
+	return list
+}
+
+func (g *Getter) proxies() []string {
+	if len(g.proxyList) > 0 {
+		return g.proxyList
+	}
+	return DefaultProxyList
+}
+
+// getModule resolves path to a module and version, fetches it from the first
+// proxy that answers, and unpacks it into session.GoPath() under
+// pkg/mod/$module@$version. It records the resolution in g.buildList (keyed
+// by module path rather than repo URL, for the hint building in Get) and in
+// g.repoPackages (keyed by importPath).
+func (g *Getter) getModule(ctx context.Context, importPath string, insecure bool) error {
+	// We don't have a separate notion of "module root" from "repo root" in
+	// this fork, so reuse the VCS-based root discovery to work out the
+	// module path. This is good enough for the hosts we support; it doesn't
+	// handle a go.mod living at a sub-directory of the repo (a "nested
+	// module"), which upstream handles by probing go.mod files on the way
+	// up the path.
+	root, err := g.repoRootForImportPath(ctx, importPath, insecure)
+	if err != nil {
+		return err
+	}
+	modulePath := root.root
+
+	if g.buildList == nil {
+		g.buildList = map[string]string{}
+	}
+	if v, ok := g.buildList[modulePath]; ok {
+		_ = v
+		return nil
+	}
+
+	version, err := g.resolveModuleVersion(ctx, modulePath)
+	if err != nil {
+		return err
+	}
+
+	if err := g.fetchModule(ctx, modulePath, version); err != nil {
+		return err
+	}
+
+	g.buildList[modulePath] = version
+	g.repoPackages[importPath] = &repoPackage{root: root, rev: version}
+
+	// Hint emission for module mode lives in Get, alongside the GOPATH
+	// path's hint loop, so that it covers every module resolved into
+	// g.buildList so far rather than just this one importPath.
+	return nil
+}
+
+// resolveModuleVersion returns the version of modulePath to use: a pinned
+// go.mod require line if one was supplied via SetGoMod, otherwise whatever
+// the first responsive proxy reports for @latest.
+func (g *Getter) resolveModuleVersion(ctx context.Context, modulePath string) (string, error) {
+	if v, ok := g.goMod[modulePath]; ok {
+		return v, nil
+	}
+
+	var lastErr error
+	for _, base := range g.proxies() {
+		info, err := g.proxyLatest(ctx, base, modulePath)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return info.Version, nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no proxies configured")
+	}
+	return "", fmt.Errorf("module %s: resolving $latest: %v", modulePath, lastErr)
+}
+
+type proxyInfo struct {
+	Version string
+	Time    string
+}
+
+func (g *Getter) proxyLatest(ctx context.Context, base, modulePath string) (*proxyInfo, error) {
+	escaped, err := escapeModulePath(modulePath)
+	if err != nil {
+		return nil, err
+	}
+	body, err := g.proxyGet(ctx, base+"/"+escaped+"/@latest")
+	if err != nil {
+		return nil, err
+	}
+	var info proxyInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("decoding @latest: %v", err)
+	}
+	return &info, nil
+}
+
+// fetchModule downloads modulePath@version's zip from the first proxy that
+// has it, verifies it against the proxy's ziphash before trusting any of its
+// contents, and unpacks it into session.GoPath() at pkg/mod/$module@$version.
+//
+// TODO: fall back to a sumdb-supplied hash (a go.sum line) when the proxy
+// doesn't publish a ziphash, instead of failing closed the way we do now.
+func (g *Getter) fetchModule(ctx context.Context, modulePath, version string) error {
+	escaped, err := escapeModulePath(modulePath)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for _, base := range g.proxies() {
+		zipBody, err := g.proxyGet(ctx, base+"/"+escaped+"/@v/"+version+".zip")
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		r, err := zip.NewReader(bytes.NewReader(zipBody), int64(len(zipBody)))
+		if err != nil {
+			return fmt.Errorf("module %s@%s: reading zip: %v", modulePath, version, err)
+		}
+		if err := g.verifyZip(ctx, base, escaped, modulePath, version, r.File); err != nil {
+			return err
+		}
+		return g.unpackModuleZip(modulePath, version, r)
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no proxies configured")
+	}
+	return fmt.Errorf("module %s@%s: %v", modulePath, version, lastErr)
+}
+
+// verifyZip checks a downloaded zip against $base/$module/@v/$ver.ziphash,
+// which is the same "h1:" directory hash recorded in go.sum. Fetching the
+// ziphash is not optional: if it can't be retrieved we refuse to unpack
+// rather than trust an unverified zip from the network.
+func (g *Getter) verifyZip(ctx context.Context, base, escapedModule, modulePath, version string, files []*zip.File) error {
+	wantBody, err := g.proxyGet(ctx, base+"/"+escapedModule+"/@v/"+version+".ziphash")
+	if err != nil {
+		return fmt.Errorf("module %s@%s: fetching ziphash: %v", modulePath, version, err)
+	}
+	want := strings.TrimSpace(string(wantBody))
+	got, err := moduleZipHash(files)
+	if err != nil {
+		return fmt.Errorf("module %s@%s: hashing zip: %v", modulePath, version, err)
+	}
+	if want != got {
+		return fmt.Errorf("module %s@%s: checksum mismatch: have %s, want %s", modulePath, version, got, want)
+	}
+	return nil
+}
+
+// moduleZipHash computes the dirhash "h1:" hash of a module zip's contents:
+// the sha256 of each file is hashed together with its name into a manifest
+// line, the manifest lines are sorted by name, and the sha256 of the
+// resulting manifest is base64-encoded. This mirrors the algorithm used to
+// produce the h1: lines recorded in go.sum and served as $ver.ziphash.
+func moduleZipHash(files []*zip.File) (string, error) {
+	type entry struct {
+		name string
+		sum  []byte
+	}
+	entries := make([]entry, 0, len(files))
+	for _, f := range files {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, rc)
+		rc.Close()
+		if err != nil {
+			return "", err
+		}
+		entries = append(entries, entry{name: f.Name, sum: h.Sum(nil)})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+
+	manifest := sha256.New()
+	for _, e := range entries {
+		fmt.Fprintf(manifest, "%x  %s\n", e.sum, e.name)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(manifest.Sum(nil)), nil
+}
+
+func (g *Getter) unpackModuleZip(modulePath, version string, r *zip.Reader) error {
+	return unpackZipEntries(g.GoPath(), modulePath, version, r.File)
+}
+
+// zipUnpackFS is the subset of session.GoPath()'s filesystem that unpacking
+// a module zip needs.
+type zipUnpackFS interface {
+	MkdirAll(path string, perm os.FileMode) error
+	Create(filename string) (io.WriteCloser, error)
+}
+
+// unpackZipEntries does the actual unpacking of a module zip's file list
+// into fs, rejecting any entry whose path would escape the module root. It's
+// split out from unpackModuleZip, which supplies the real session
+// filesystem, so the zip-slip and layout logic can be exercised in tests
+// without a real session.
+func unpackZipEntries(fs zipUnpackFS, modulePath, version string, files []*zip.File) error {
+	dest := "pkg/mod/" + modulePath + "@" + version
+	prefix := modulePath + "@" + version + "/"
+
+	for _, f := range files {
+		name := strings.TrimPrefix(f.Name, prefix)
+		if name == f.Name {
+			// Entry isn't under the expected module@version/ prefix; skip it
+			// rather than writing outside dest.
+			continue
+		}
+		if isUnsafeZipEntryName(name) {
+			// zip-slip: a "../" segment (or an absolute path) would write
+			// outside dest. A proxy response should never contain one; treat
+			// it as a corrupt/hostile zip rather than silently sanitizing it.
+			return fmt.Errorf("module %s@%s: zip entry %q escapes module root", modulePath, version, f.Name)
+		}
+		target := dest + "/" + name
+		if f.FileInfo().IsDir() {
+			if err := fs.MkdirAll(target, 0777); err != nil {
+				return err
+			}
+			continue
+		}
+		if dir, _ := splitDir(target); dir != "" {
+			if err := fs.MkdirAll(dir, 0777); err != nil {
+				return err
+			}
+		}
+		if err := writeZipEntry(fs, target, f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeZipEntry(fs interface {
+	Create(filename string) (io.WriteCloser, error)
+}, target string, f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	out, err := fs.Create(target)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, rc)
+	return err
+}
+
+// isUnsafeZipEntryName reports whether a zip entry's path (already relative
+// to the module root) could escape the directory it's being unpacked into,
+// via an absolute path or a "../" segment.
+func isUnsafeZipEntryName(name string) bool {
+	if path.IsAbs(name) {
+		return true
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+func splitDir(p string) (dir, file string) {
+	i := strings.LastIndex(p, "/")
+	if i < 0 {
+		return "", p
+	}
+	return p[:i], p[i+1:]
+}
+
+// proxyGet performs a GOPROXY-protocol GET against url and returns the body.
+func (g *Getter) proxyGet(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// escapeModulePath applies the module path escaping used by the GOPROXY
+// protocol: every uppercase letter is replaced by an exclamation mark
+// followed by its lowercase equivalent, so that paths are safe on
+// case-insensitive file systems and proxy URLs.
+func escapeModulePath(path string) (string, error) {
+	var buf bytes.Buffer
+	for _, r := range path {
+		switch {
+		case r == '!':
+			return "", fmt.Errorf("invalid module path %q: disallowed character %q", path, r)
+		case r >= 'A' && r <= 'Z':
+			buf.WriteByte('!')
+			buf.WriteRune(r - 'A' + 'a')
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String(), nil
+}
+
+// parseGoModRequires extracts "module version" pairs from the require
+// block(s) of a go.mod file. It's intentionally forgiving: it only looks for
+// lines that look like require directives and ignores everything else
+// (replace, exclude, build constraints, etc.) since all we need here is the
+// version pin.
+func parseGoModRequires(data []byte) map[string]string {
+	requires := map[string]string{}
+	inBlock := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+		switch {
+		case line == "require (":
+			inBlock = true
+			continue
+		case inBlock && line == ")":
+			inBlock = false
+			continue
+		case inBlock:
+			addRequireLine(requires, line)
+		case strings.HasPrefix(line, "require "):
+			addRequireLine(requires, strings.TrimPrefix(line, "require "))
+		}
+	}
+	return requires
+}
+
+func addRequireLine(requires map[string]string, line string) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return
+	}
+	requires[fields[0]] = fields[1]
+}
@@ -0,0 +1,150 @@
+package get
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestIsUnsafeZipEntryName(t *testing.T) {
+	tests := []struct {
+		name   string
+		unsafe bool
+	}{
+		{"foo.go", false},
+		{"sub/foo.go", false},
+		{"../evil.go", true},
+		{"sub/../../evil.go", true},
+		{"/etc/passwd", true},
+		{"..", true},
+	}
+	for _, tt := range tests {
+		if got := isUnsafeZipEntryName(tt.name); got != tt.unsafe {
+			t.Errorf("isUnsafeZipEntryName(%q) = %v, want %v", tt.name, got, tt.unsafe)
+		}
+	}
+}
+
+type fakeZipUnpackFS struct {
+	created []string
+	dirs    []string
+}
+
+func (f *fakeZipUnpackFS) MkdirAll(path string, perm os.FileMode) error {
+	f.dirs = append(f.dirs, path)
+	return nil
+}
+
+func (f *fakeZipUnpackFS) Create(filename string) (io.WriteCloser, error) {
+	f.created = append(f.created, filename)
+	return nopWriteCloser{&bytes.Buffer{}}, nil
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func buildZip(t *testing.T, entries map[string]string) *zip.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range entries {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("read zip back: %v", err)
+	}
+	return r
+}
+
+func TestUnpackZipEntriesRejectsZipSlip(t *testing.T) {
+	r := buildZip(t, map[string]string{
+		"example.com/mod@v1.0.0/good.go":       "package mod\n",
+		"example.com/mod@v1.0.0/../../evil.go": "package evil\n",
+	})
+
+	fs := &fakeZipUnpackFS{}
+	err := unpackZipEntries(fs, "example.com/mod", "v1.0.0", r.File)
+	if err == nil {
+		t.Fatal("expected an error for a zip-slip entry, got nil")
+	}
+	for _, name := range fs.created {
+		if name != "pkg/mod/example.com/mod@v1.0.0/good.go" {
+			t.Errorf("unexpected file written outside the module root: %q", name)
+		}
+	}
+}
+
+func TestUnpackZipEntriesRejectsAbsolutePath(t *testing.T) {
+	r := buildZip(t, map[string]string{
+		"example.com/mod@v1.0.0//etc/passwd": "root:x:0:0::/root:/bin/sh\n",
+	})
+
+	fs := &fakeZipUnpackFS{}
+	err := unpackZipEntries(fs, "example.com/mod", "v1.0.0", r.File)
+	if err == nil {
+		t.Fatal("expected an error for an absolute-path entry, got nil")
+	}
+	if len(fs.created) != 0 {
+		t.Errorf("expected no files written, got %v", fs.created)
+	}
+}
+
+func TestUnpackZipEntriesWritesIntoModuleRoot(t *testing.T) {
+	r := buildZip(t, map[string]string{
+		"example.com/mod@v1.0.0/go.mod":     "module example.com/mod\n",
+		"example.com/mod@v1.0.0/sub/foo.go": "package sub\n",
+	})
+
+	fs := &fakeZipUnpackFS{}
+	if err := unpackZipEntries(fs, "example.com/mod", "v1.0.0", r.File); err != nil {
+		t.Fatalf("unpackZipEntries: %v", err)
+	}
+
+	want := map[string]bool{
+		"pkg/mod/example.com/mod@v1.0.0/go.mod":     true,
+		"pkg/mod/example.com/mod@v1.0.0/sub/foo.go": true,
+	}
+	for _, name := range fs.created {
+		if !want[name] {
+			t.Errorf("unexpected file written: %q", name)
+		}
+		delete(want, name)
+	}
+	if len(want) != 0 {
+		t.Errorf("expected files not written: %v", want)
+	}
+}
+
+// TestModuleZipHashKnownVector checks moduleZipHash against a value
+// independently computed with golang.org/x/mod/sumdb/dirhash.Hash1 (the
+// real algorithm GOPROXY ziphashes are produced with) for the same file
+// list and contents, so a regression back to a raw sha256-of-the-zip (the
+// bug this replaced) would be caught.
+func TestModuleZipHashKnownVector(t *testing.T) {
+	r := buildZip(t, map[string]string{
+		"example.com/mod@v1.0.0/go.mod": "module example.com/mod\n\ngo 1.21\n",
+		"example.com/mod@v1.0.0/foo.go": "package mod\n\nfunc Foo() string { return \"foo\" }\n",
+	})
+
+	const want = "h1:PUgoJ9NoM9GrLmJBWHfr5S8PpNTsAokri0J+QI0FrJ4="
+	got, err := moduleZipHash(r.File)
+	if err != nil {
+		t.Fatalf("moduleZipHash: %v", err)
+	}
+	if got != want {
+		t.Errorf("moduleZipHash = %q, want %q", got, want)
+	}
+}
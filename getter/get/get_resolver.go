@@ -0,0 +1,76 @@
+package get
+
+import (
+	"context"
+	"strings"
+)
+
+// VersionResolver lets a caller pin the revision Getter checks a repo out
+// to, instead of always taking whatever the VCS reports as tip. Set one via
+// NewWithVersionResolver or SetVersionResolver.
+type VersionResolver interface {
+	// Resolve returns the revision (tag, branch or commit, in whatever form
+	// the underlying VCS accepts) that root should be checked out to. An
+	// empty rev with a nil error means "no preference, use the default".
+	Resolve(ctx context.Context, root *repoRoot) (rev string, err error)
+}
+
+// SetVersionResolver installs r as the resolver consulted for every repo
+// Getter downloads or updates from this point on.
+func (g *Getter) SetVersionResolver(r VersionResolver) {
+	g.versionResolver = r
+}
+
+// resolveRev asks the configured VersionResolver (if any) what revision
+// root should be pinned to.
+func (g *Getter) resolveRev(ctx context.Context, root *repoRoot) (string, error) {
+	if g.versionResolver == nil {
+		return "", nil
+	}
+	return g.versionResolver.Resolve(ctx, root)
+}
+
+// repoPackage records, for a single downloaded package, which repo it came
+// from and which revision (if any) it was pinned to, so that hint building
+// can tell gitcache the exact ref it should fetch.
+type repoPackage struct {
+	root *repoRoot
+	rev  string
+}
+
+// StaticVersionResolver pins repos by import path prefix: the longest
+// matching key in the map wins, e.g. {"github.com/foo/bar": "v1.2.3"}
+// applies to github.com/foo/bar and anything under it.
+type StaticVersionResolver map[string]string
+
+// Resolve implements VersionResolver.
+func (m StaticVersionResolver) Resolve(ctx context.Context, root *repoRoot) (string, error) {
+	best, bestLen := "", -1
+	for prefix, rev := range m {
+		if prefix != root.root && !strings.HasPrefix(root.root, prefix+"/") {
+			continue
+		}
+		if len(prefix) > bestLen {
+			best, bestLen = rev, len(prefix)
+		}
+	}
+	return best, nil
+}
+
+// GoModVersionResolver pins repos to the versions named in a go.mod's
+// require directives, so a playground snippet can pin its dependencies the
+// same way a real module would.
+type GoModVersionResolver struct {
+	requires map[string]string // module path -> version, see parseGoModRequires
+}
+
+// NewGoModVersionResolver parses the require directives out of the given
+// go.mod contents.
+func NewGoModVersionResolver(data []byte) *GoModVersionResolver {
+	return &GoModVersionResolver{requires: parseGoModRequires(data)}
+}
+
+// Resolve implements VersionResolver.
+func (r *GoModVersionResolver) Resolve(ctx context.Context, root *repoRoot) (string, error) {
+	return r.requires[root.root], nil
+}
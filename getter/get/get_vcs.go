@@ -0,0 +1,434 @@
+package get
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dave/services/fsutil"
+	billy "gopkg.in/src-d/go-billy.v4"
+)
+
+// checkoutFS is the subset of session.GoPath()'s filesystem that a VCS
+// checkout needs: enough to check whether a checkout already exists and to
+// make room for a new one. downloadPackage already relies on exactly these
+// two methods being present on whatever session.GoPath() returns.
+type checkoutFS interface {
+	Stat(path string) (os.FileInfo, error)
+	MkdirAll(path string, perm os.FileMode) error
+}
+
+// repoRoot describes the repository backing an import path: where it lives
+// upstream, which VCS serves it, and where (if anywhere) it's already
+// checked out locally.
+type repoRoot struct {
+	vcs  *vcsCmd
+	repo string // repository URL, as the VCS would clone it
+	root string // import path corresponding to the repository root
+	dir  string // local checkout directory under session.GoPath()
+
+	// exists is true when dir already holds a checkout of this repo, so
+	// downloadPackage knows whether to create a fresh one or download
+	// incremental updates into what's there.
+	exists bool
+}
+
+// vcsCmd is the (per-VCS) command set a repoRoot drives to materialize or
+// refresh a checkout. The real clone/pull/checkout-to-rev invocations are
+// injected per-VCS via the function fields below, the same way cmd/go keeps
+// per-VCS behaviour behind a vcsCmd table rather than hardcoding git/hg/svn
+// logic into repoRoot itself; this also lets tests exercise repoRoot.create
+// and repoRoot.download without a real git binary or network access.
+type vcsCmd struct {
+	name string
+
+	checkoutFn func(ctx context.Context, fs checkoutFS, root *repoRoot) error
+	pullFn     func(ctx context.Context, root *repoRoot) error
+	resetToFn  func(ctx context.Context, root *repoRoot, rev string) error
+}
+
+// checkout clones root's repo into fs at root.dir.
+func (v *vcsCmd) checkout(ctx context.Context, fs checkoutFS, root *repoRoot) error {
+	if v.checkoutFn == nil {
+		// TODO: shell out to (or otherwise drive) the actual VCS clone
+		// command for v.name against fs. Session filesystems are virtual,
+		// so this needs the same indirection the rest of this package uses
+		// for git, not a literal os/exec call.
+		return nil
+	}
+	return v.checkoutFn(ctx, fs, root)
+}
+
+// pull refreshes an existing checkout at root.dir with new refs from
+// upstream, moving it to the latest revision the VCS fetched.
+func (v *vcsCmd) pull(ctx context.Context, root *repoRoot) error {
+	if v.pullFn == nil {
+		// TODO: shell out to (or otherwise drive) the actual VCS fetch/pull
+		// command for v.name in root.dir.
+		return nil
+	}
+	return v.pullFn(ctx, root)
+}
+
+// resetTo moves the checkout at root.dir to rev.
+func (v *vcsCmd) resetTo(ctx context.Context, root *repoRoot, rev string) error {
+	if v.resetToFn == nil {
+		// TODO: shell out to (or otherwise drive) the actual VCS
+		// checkout/reset command for v.name in root.dir.
+		return nil
+	}
+	return v.resetToFn(ctx, root, rev)
+}
+
+// create makes the first checkout of root into fs, then, if rev is
+// non-empty, resets it to that revision. An empty rev leaves the checkout
+// at whatever the VCS considers its default tip.
+func (r *repoRoot) create(ctx context.Context, fs checkoutFS, rev string) error {
+	if err := r.vcs.checkout(ctx, fs, r); err != nil {
+		return err
+	}
+	if rev == "" {
+		return nil
+	}
+	return r.vcs.resetTo(ctx, r, rev)
+}
+
+// download refreshes an existing checkout of root, then, if rev is
+// non-empty, resets it to that revision. An empty rev leaves the checkout
+// wherever the refresh put it (typically the VCS's default tip).
+func (r *repoRoot) download(ctx context.Context, rev string) error {
+	if err := r.vcs.pull(ctx, r); err != nil {
+		return err
+	}
+	if rev == "" {
+		return nil
+	}
+	return r.vcs.resetTo(ctx, r, rev)
+}
+
+// isSecure reports whether repo's scheme is one that downloadPackage should
+// allow without the caller passing insecure.
+func isSecure(repo string) bool {
+	u, err := url.Parse(repo)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "https", "git+ssh", "ssh":
+		return true
+	}
+	return false
+}
+
+// vcsFromDir looks up the repoRoot already recorded (in g.downloadRootCache,
+// keyed by checkout dir) for dir or one of its parents up to srcRoot, so
+// that a package found already on disk can still be attributed to the repo
+// that owns it without re-resolving the import path.
+func (g *Getter) vcsFromDir(dir, srcRoot string) (*repoRoot, error) {
+	dir = filepath.Clean(dir)
+	srcRoot = filepath.Clean(srcRoot)
+	if len(dir) <= len(srcRoot) || dir[len(srcRoot)] != filepath.Separator {
+		return nil, fmt.Errorf("directory %q is outside source root %q", dir, srcRoot)
+	}
+
+	origDir := dir
+	for len(dir) > len(srcRoot) {
+		if root, ok := g.downloadRootCache[dir]; ok {
+			return root, nil
+		}
+		ndir := filepath.Dir(dir)
+		if len(ndir) >= len(dir) {
+			break
+		}
+		dir = ndir
+	}
+
+	return nil, fmt.Errorf("directory %q is not using a known version control system", origDir)
+}
+
+// vcsPath describes how to turn an importPath matching a well-known hosting
+// prefix into a git repository URL.
+type vcsPath struct {
+	prefix string // import path prefix this entry applies to
+	re     string // pattern for the import path, with a named "root" group
+	vcs    string // VCS to use, passed to vcsByCmd
+	repo   string // repo URL template, expanded against the regexp match
+
+	regexp *regexp.Regexp // compiled form of re, set by init
+}
+
+// vcsPaths maps well-known hosting prefixes straight to a git repo URL,
+// without needing a discovery fetch. Anything not matched here falls
+// through to the go-import meta tag discovery in repoRootForImportDynamic.
+// This is a reduced version of cmd/go's table: only the hosts this fork has
+// actually needed so far, and git only, since that's all vcsByCmd supports.
+var vcsPaths = []*vcsPath{
+	{
+		prefix: "github.com/",
+		re:     `^(?P<root>github\.com/[A-Za-z0-9_.\-]+/[A-Za-z0-9_.\-]+)(/[A-Za-z0-9_.\-]+)*$`,
+		vcs:    "git",
+		repo:   "https://{root}",
+	},
+	{
+		prefix: "bitbucket.org/",
+		re:     `^(?P<root>bitbucket\.org/[A-Za-z0-9_.\-]+/[A-Za-z0-9_.\-]+)(/[A-Za-z0-9_.\-]+)*$`,
+		vcs:    "git",
+		repo:   "https://{root}",
+	},
+	{
+		prefix: "gopkg.in/",
+		re:     `^(?P<root>gopkg\.in/(?:[A-Za-z0-9-]+/)?[A-Za-z0-9_.\-]+\.v[0-9]+)(/[A-Za-z0-9_.\-]+)*$`,
+		vcs:    "git",
+		repo:   "https://{root}",
+	},
+}
+
+func init() {
+	for _, entry := range vcsPaths {
+		entry.regexp = regexp.MustCompile(entry.re)
+	}
+}
+
+var errUnknownSite = errors.New("dynamic lookup required to find mapping")
+
+var httpPrefixRE = regexp.MustCompile(`^https?:`)
+
+// repoRootForImportPath analyzes importPath to determine the VCS and
+// repository to use: a well-known hosting prefix resolves directly via
+// vcsPaths, anything else falls back to fetching importPath's go-import
+// meta tag (repoRootForImportDynamic).
+func (g *Getter) repoRootForImportPath(ctx context.Context, importPath string, insecure bool) (*repoRoot, error) {
+	rr, err := g.repoRootFromVCSPaths(importPath, vcsPaths)
+	if err == errUnknownSite {
+		rr, err = g.repoRootForImportDynamic(ctx, importPath, insecure)
+		if err != nil {
+			err = fmt.Errorf("unrecognized import path %q (%v)", importPath, err)
+		}
+	}
+	return rr, err
+}
+
+// repoRootFromVCSPaths attempts to map importPath to a repoRoot using the
+// static prefix/regexp entries in paths.
+func (g *Getter) repoRootFromVCSPaths(importPath string, paths []*vcsPath) (*repoRoot, error) {
+	if loc := httpPrefixRE.FindStringIndex(importPath); loc != nil {
+		// The importPath has been cleaned, so has only one slash; put it
+		// back on the RHS of the error message at least.
+		return nil, fmt.Errorf("%q not allowed in import path", importPath[loc[0]:loc[1]]+"//")
+	}
+	for _, srv := range paths {
+		if !strings.HasPrefix(importPath, srv.prefix) {
+			continue
+		}
+		m := srv.regexp.FindStringSubmatch(importPath)
+		if m == nil {
+			return nil, fmt.Errorf("invalid %s import path %q", srv.prefix, importPath)
+		}
+		match := map[string]string{"prefix": srv.prefix, "import": importPath}
+		for i, name := range srv.regexp.SubexpNames() {
+			if name != "" && match[name] == "" {
+				match[name] = m[i]
+			}
+		}
+		vcs := g.vcsByCmd(srv.vcs)
+		if vcs == nil {
+			return nil, fmt.Errorf("unknown version control system %q", srv.vcs)
+		}
+		return &repoRoot{
+			vcs:  vcs,
+			repo: expand(match, srv.repo),
+			root: match["root"],
+		}, nil
+	}
+	return nil, errUnknownSite
+}
+
+// repoRootForImportDynamic finds a repoRoot for a host not covered by
+// vcsPaths, by fetching importPath's go-import discovery page.
+//
+// Unlike cmd/go, this doesn't re-fetch the matched meta import's own prefix
+// to confirm it agrees with importPath when the two differ (the
+// anti-preemption check for e.g. "uni.edu/bob/project" claiming repo root
+// "evilroot.com"). That's a real gap for untrusted hosts; porting the
+// second fetch-and-compare is tracked as follow-up work, not done here.
+func (g *Getter) repoRootForImportDynamic(ctx context.Context, importPath string, insecure bool) (*repoRoot, error) {
+	slash := strings.Index(importPath, "/")
+	if slash < 0 {
+		slash = len(importPath)
+	}
+	host := importPath[:slash]
+	if !strings.Contains(host, ".") {
+		return nil, errors.New("import path does not begin with hostname")
+	}
+
+	urlStr, body, err := g.fetchGoImportMeta(ctx, importPath, insecure)
+	if err != nil {
+		msg := "https fetch: %v"
+		if insecure {
+			msg = "http/" + msg
+		}
+		return nil, fmt.Errorf(msg, err)
+	}
+	defer body.Close()
+
+	imports, err := parseMetaGoImports(body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", importPath, err)
+	}
+	mmi, err := matchGoImport(imports, importPath)
+	if err != nil {
+		if _, ok := err.(ImportMismatchError); !ok {
+			return nil, fmt.Errorf("parse %s: %v", urlStr, err)
+		}
+		return nil, fmt.Errorf("parse %s: no go-import meta tags (%s)", urlStr, err)
+	}
+
+	if err := validateRepoRoot(mmi.RepoRoot); err != nil {
+		return nil, fmt.Errorf("%s: invalid repo root %q: %v", urlStr, mmi.RepoRoot, err)
+	}
+	vcs := g.vcsByCmd(mmi.VCS)
+	if vcs == nil {
+		return nil, fmt.Errorf("%s: unknown vcs %q", urlStr, mmi.VCS)
+	}
+	return &repoRoot{
+		vcs:  vcs,
+		repo: mmi.RepoRoot,
+		root: mmi.Prefix,
+	}, nil
+}
+
+// validateRepoRoot reports whether repoRoot looks like a URL with a scheme.
+func validateRepoRoot(repoRoot string) error {
+	u, err := url.Parse(repoRoot)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" {
+		return errors.New("no scheme")
+	}
+	return nil
+}
+
+var insecureHTTPClient = &http.Client{
+	Timeout: 5 * time.Second,
+	Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	},
+}
+
+// fetchGoImportMeta fetches importPath's go-import discovery page over
+// https, falling back to http only when insecure allows it. A non-200
+// response is still returned: a go-import meta tag served on an error page
+// is valid, the same as cmd/go treats it.
+func (g *Getter) fetchGoImportMeta(ctx context.Context, importPath string, insecure bool) (string, io.ReadCloser, error) {
+	fetch := func(scheme string) (string, *http.Response, error) {
+		u, err := url.Parse(scheme + "://" + importPath)
+		if err != nil {
+			return "", nil, err
+		}
+		u.RawQuery = "go-get=1"
+		req, err := http.NewRequest("GET", u.String(), nil)
+		if err != nil {
+			return "", nil, err
+		}
+		req = req.WithContext(ctx)
+		client := http.DefaultClient
+		if insecure && scheme == "https" {
+			client = insecureHTTPClient
+		}
+		resp, err := client.Do(req)
+		return u.String(), resp, err
+	}
+
+	urlStr, resp, err := fetch("https")
+	if err != nil && insecure {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		urlStr, resp, err = fetch("http")
+	}
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return "", nil, err
+	}
+	return urlStr, resp.Body, nil
+}
+
+// vcsByCmd returns the vcsCmd that drives the named VCS, or nil if it isn't
+// one this fork supports. Only git is wired up: it's the only VCS the
+// shared cache.Request (and the gitcache service behind it) fetches for.
+func (g *Getter) vcsByCmd(name string) *vcsCmd {
+	switch name {
+	case "git":
+		return g.gitVCS()
+	}
+	return nil
+}
+
+// gitVCS returns the vcsCmd that drives checkouts and updates through
+// g.gitreq, the same cache.Request the hint-building loop in Get feeds.
+func (g *Getter) gitVCS() *vcsCmd {
+	return &vcsCmd{
+		name:       "git",
+		checkoutFn: g.gitCheckout,
+		pullFn:     g.gitPull,
+		resetToFn:  g.gitResetTo,
+	}
+}
+
+// gitCheckout clones root.repo through the shared cache.Request and copies
+// the resulting worktree into fs at root.dir.
+func (g *Getter) gitCheckout(ctx context.Context, fs checkoutFS, root *repoRoot) error {
+	return g.gitFetchInto(ctx, fs, root, "")
+}
+
+// gitPull refreshes the checkout at root.dir with new refs from upstream.
+// cache.Request.Fetch always returns the latest worktree it has for a URL -
+// cloning it the first time, re-fetching on every call after - so calling
+// it again through the same gitreq is exactly "pull new refs" for our
+// purposes, and is what lets the hint-driven gitcache prefetch still work
+// for -u refreshes the way it does for the initial checkout.
+func (g *Getter) gitPull(ctx context.Context, root *repoRoot) error {
+	return g.gitFetchInto(ctx, g.GoPath(), root, "")
+}
+
+// gitResetTo moves the checkout at root.dir to rev, by re-fetching root.repo
+// pinned to that revision. This is the same "repo@rev" encoding the hint
+// loop in Get already uses for gitcache hints, so gitcache's Fetch already
+// knows how to resolve it to a worktree at that exact commit.
+func (g *Getter) gitResetTo(ctx context.Context, root *repoRoot, rev string) error {
+	return g.gitFetchInto(ctx, g.GoPath(), root, rev)
+}
+
+// gitFetchInto fetches root.repo (pinned to rev, if rev is non-empty)
+// through the shared cache.Request and copies the resulting worktree into
+// fs at root.dir.
+func (g *Getter) gitFetchInto(ctx context.Context, fs checkoutFS, root *repoRoot, rev string) error {
+	if g.gitreq == nil {
+		return fmt.Errorf("get %s: no cache.Request configured to fetch from", root.repo)
+	}
+	url := root.repo
+	if rev != "" {
+		url = root.repo + "@" + rev
+	}
+	worktree, err := g.gitreq.Fetch(ctx, url)
+	if err != nil {
+		return err
+	}
+	dest, ok := fs.(billy.Filesystem)
+	if !ok {
+		return fmt.Errorf("get %s: checkout filesystem does not support copying a worktree into it", root.repo)
+	}
+	return fsutil.Copy(dest, root.dir, worktree, "/")
+}
@@ -0,0 +1,99 @@
+package get
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+)
+
+type fakeCheckoutFS struct{}
+
+func (fakeCheckoutFS) Stat(path string) (os.FileInfo, error)        { return nil, os.ErrNotExist }
+func (fakeCheckoutFS) MkdirAll(path string, perm os.FileMode) error { return nil }
+
+// TestRepoRootDownloadAdvancesWorkingTree exercises the incremental-update
+// path added to fix the "root exists" panic: calling download twice on the
+// same repoRoot should pull new refs both times, actually advancing the
+// working tree rather than getting stuck after the first refresh.
+func TestRepoRootDownloadAdvancesWorkingTree(t *testing.T) {
+	var head string
+	pulls := 0
+	r := &repoRoot{
+		repo: "https://example.com/foo.git",
+		root: "example.com/foo",
+		dir:  "/gopath/src/example.com/foo",
+		vcs: &vcsCmd{
+			name: "git",
+			pullFn: func(ctx context.Context, root *repoRoot) error {
+				pulls++
+				head = fmt.Sprintf("rev-%d", pulls)
+				return nil
+			},
+		},
+	}
+
+	if err := r.download(context.Background(), ""); err != nil {
+		t.Fatalf("first download: %v", err)
+	}
+	first := head
+	if first == "" {
+		t.Fatal("first download did not advance the working tree")
+	}
+
+	if err := r.download(context.Background(), ""); err != nil {
+		t.Fatalf("second download: %v", err)
+	}
+	if head == first {
+		t.Fatalf("second download did not advance the working tree: still at %q", first)
+	}
+	if pulls != 2 {
+		t.Fatalf("expected download to pull twice, got %d", pulls)
+	}
+}
+
+// TestRepoRootCreateResetsToPinnedRev exercises the VersionResolver wiring:
+// a non-empty rev passed to create should be applied via resetTo after the
+// initial checkout.
+func TestRepoRootCreateResetsToPinnedRev(t *testing.T) {
+	var resetRev string
+	r := &repoRoot{
+		vcs: &vcsCmd{
+			name: "git",
+			resetToFn: func(ctx context.Context, root *repoRoot, rev string) error {
+				resetRev = rev
+				return nil
+			},
+		},
+	}
+
+	if err := r.create(context.Background(), fakeCheckoutFS{}, "v1.2.3"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if resetRev != "v1.2.3" {
+		t.Fatalf("create did not reset the checkout to the pinned rev: got %q", resetRev)
+	}
+}
+
+// TestRepoRootCreateWithoutRevDoesNotReset checks the complementary case: no
+// resolver means no reset call, leaving the checkout at the VCS's default
+// tip.
+func TestRepoRootCreateWithoutRevDoesNotReset(t *testing.T) {
+	resetCalled := false
+	r := &repoRoot{
+		vcs: &vcsCmd{
+			name: "git",
+			resetToFn: func(ctx context.Context, root *repoRoot, rev string) error {
+				resetCalled = true
+				return nil
+			},
+		},
+	}
+
+	if err := r.create(context.Background(), fakeCheckoutFS{}, ""); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if resetCalled {
+		t.Fatal("create reset the checkout when no rev was pinned")
+	}
+}
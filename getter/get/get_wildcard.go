@@ -0,0 +1,182 @@
+package get
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// GetPatterns resolves and downloads each of patterns, expanding any "..."
+// wildcards the way `go get` does: the wildcard-free prefix of the pattern
+// is used to locate and fetch a repo even when nothing matching the pattern
+// exists on disk yet, and only once that material is present is the
+// pattern re-expanded against the concrete packages it now contains. It
+// returns the full set of concrete import paths that were matched and
+// downloaded.
+//
+// Wildcard expansion only supports ModeGOPATH: discovering the packages a
+// "..." pattern matches means walking a real checkout on disk, which
+// ModeModule has no equivalent for (a module's package list isn't known
+// until its zip is fetched and unpacked, and nothing here lists a module's
+// contents without doing that first). A pattern with no "..." works in
+// either mode, since then there's nothing to discover - it's just passed
+// straight through to Get.
+func (g *Getter) GetPatterns(ctx context.Context, patterns []string, mode Mode, update, insecure, single, tests bool) ([]string, error) {
+	var matched []string
+	for _, pattern := range patterns {
+		paths, err := g.getPattern(ctx, pattern, mode, update, insecure, single, tests)
+		if err != nil {
+			return matched, err
+		}
+		matched = append(matched, paths...)
+	}
+	return matched, nil
+}
+
+func (g *Getter) getPattern(ctx context.Context, pattern string, mode Mode, update, insecure, single, tests bool) ([]string, error) {
+	if !strings.Contains(pattern, "...") {
+		if err := g.getOne(ctx, pattern, mode, update, insecure, single, tests); err != nil {
+			return nil, err
+		}
+		return []string{pattern}, nil
+	}
+
+	if mode == ModeModule {
+		return nil, fmt.Errorf("get %s: wildcard pattern expansion is not supported in module mode", pattern)
+	}
+
+	prefix := patternPrefix(pattern)
+
+	// Locate and fetch the repo containing prefix, even though prefix
+	// itself may not be a real package (e.g. "github.com/foo/bar/...").
+	// single=true: we just want the checkout on disk, not its dependencies.
+	var stk ImportStack
+	if err := g.download(ctx, prefix, nil, &stk, update, insecure, true, tests); err != nil {
+		return nil, err
+	}
+
+	// download records repoPackages keyed by the canonical ImportPath (see
+	// the "path = p.ImportPath" comment in download), which may differ from
+	// the raw prefix for vanity import redirects or relative invocations.
+	// Resolve the same way before looking it up, or the lookup silently
+	// fails even though the download above succeeded.
+	canonicalPrefix, err := g.canonicalImportPath(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, ok := g.repoPackages[canonicalPrefix]
+	if !ok {
+		return nil, fmt.Errorf("get %s: could not resolve repo root for pattern prefix %s", pattern, prefix)
+	}
+
+	// Now that the repo is on disk, re-expand the pattern against the
+	// packages it actually contains.
+	concrete, err := g.expandPattern(pattern, repo.root)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range concrete {
+		if err := g.getOne(ctx, path, mode, update, insecure, single, tests); err != nil {
+			return nil, err
+		}
+	}
+
+	return concrete, nil
+}
+
+// getOne runs a single, already-concrete import path through Get, so a
+// matched wildcard path is fed through the same download/hint pipeline
+// (including the gitcache SetHints call) as a plain, non-wildcard Get.
+func (g *Getter) getOne(ctx context.Context, path string, mode Mode, update, insecure, single, tests bool) error {
+	return g.Get(ctx, path, mode, update, insecure, single, tests)
+}
+
+// canonicalImportPath resolves path the same way download's load1 does for a
+// top-level call (parent == nil), returning the canonical ImportPath so
+// callers can key repoPackages the same way download does.
+func (g *Getter) canonicalImportPath(ctx context.Context, path string) (string, error) {
+	var stk ImportStack
+	p := g.LoadImport(ctx, path, "/", nil, &stk, false)
+	if p.Error != nil && p.Error.Hard {
+		return "", p.Error
+	}
+	return p.ImportPath, nil
+}
+
+// patternPrefix returns the "..."-free prefix of pattern, with any trailing
+// slash trimmed, e.g. "github.com/foo/bar/..." -> "github.com/foo/bar".
+func patternPrefix(pattern string) string {
+	i := strings.Index(pattern, "...")
+	if i < 0 {
+		return pattern
+	}
+	return strings.TrimSuffix(pattern[:i], "/")
+}
+
+// matchPattern compiles pattern (which may contain "..." wildcards matching
+// any sequence of characters, including slashes) into a matcher function,
+// mirroring the simplified matching cmd/go uses for import path patterns.
+func matchPattern(pattern string) func(name string) bool {
+	re := "^" + strings.Replace(regexp.QuoteMeta(pattern), `\.\.\.`, `.*`, -1) + "$"
+	reg := regexp.MustCompile(re)
+	return func(name string) bool {
+		return reg.MatchString(name)
+	}
+}
+
+// dirLister is the subset of session.GoPath()'s filesystem that
+// expandPattern needs to walk the checked-out source tree.
+type dirLister interface {
+	ReadDir(path string) ([]os.FileInfo, error)
+}
+
+// expandPattern walks the checkout at root.dir (import path root.root) and
+// returns every package import path under it that matches pattern.
+func (g *Getter) expandPattern(pattern string, root *repoRoot) ([]string, error) {
+	fs, ok := g.GoPath().(dirLister)
+	if !ok {
+		return nil, fmt.Errorf("get %s: GOPATH filesystem does not support directory listing", pattern)
+	}
+	match := matchPattern(pattern)
+
+	var out []string
+	var walk func(dir, importPath string) error
+	walk = func(dir, importPath string) error {
+		entries, err := fs.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		hasGoFiles := false
+		var subdirs []os.FileInfo
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() {
+				if name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+					continue
+				}
+				subdirs = append(subdirs, entry)
+				continue
+			}
+			if strings.HasSuffix(name, ".go") {
+				hasGoFiles = true
+			}
+		}
+		if hasGoFiles && match(importPath) {
+			out = append(out, importPath)
+		}
+		for _, d := range subdirs {
+			if err := walk(dir+"/"+d.Name(), importPath+"/"+d.Name()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(root.dir, root.root); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -20,10 +20,16 @@ type Getter struct {
 	foldPath          map[string]string
 	downloadCache     map[string]bool
 	downloadRootCache map[string]*repoRoot
-	repoPackages      map[string]*repoRoot
+	repoPackages      map[string]*repoPackage
 	fetchGroup        singleflight.Group
 	fetchCacheMu      sync.Mutex
 	fetchCache        map[string]fetchResult // key is metaImportsForPrefix's importPrefix
+
+	proxyList       []string            // GOPROXY-protocol endpoints tried in order, see SetProxyList
+	goMod           map[string]string   // module path -> pinned version, parsed from a supplied go.mod, see SetGoMod
+	buildList       map[string]string   // module path -> resolved version, populated as ModeModule requests are satisfied
+	versionResolver VersionResolver     // optional, see SetVersionResolver
+	hints           map[string][]string // package path -> candidate repo URLs, accumulated across every ModeGOPATH Get call so gitcache hints from an earlier path aren't clobbered by a later one (see GetPatterns)
 }
 
 func New(session *session.Session, log io.Writer, cache *cache.Request) *Getter {
@@ -34,36 +40,90 @@ func New(session *session.Session, log io.Writer, cache *cache.Request) *Getter
 	g.packageCache = make(map[string]*Package)
 	g.foldPath = make(map[string]string)
 	g.downloadCache = make(map[string]bool)
-	g.downloadRootCache = make(map[string]*repoRoot) // key is the root dir of the repo
-	g.repoPackages = make(map[string]*repoRoot)      // key is the path of the package. NOTE: not all packages are included, but the ones we're interested in should be.
+	g.downloadRootCache = make(map[string]*repoRoot)   // key is the root dir of the repo
+	g.repoPackages = make(map[string]*repoPackage)     // key is the path of the package. NOTE: not all packages are included, but the ones we're interested in should be.
 	g.fetchCache = make(map[string]fetchResult)
+	g.hints = make(map[string][]string)
 	g.buildContext = g.BuildContext(false, "")
 	return g
 }
 
-func (g *Getter) Get(ctx context.Context, path string, update bool, insecure, single bool) error {
+// NewWithVersionResolver is New plus an initial VersionResolver, for callers
+// that always want pinned revisions (e.g. a playground snippet referencing
+// a specific commit) rather than calling SetVersionResolver separately.
+func NewWithVersionResolver(session *session.Session, log io.Writer, cache *cache.Request, resolver VersionResolver) *Getter {
+	g := New(session, log, cache)
+	g.versionResolver = resolver
+	return g
+}
+
+func (g *Getter) Get(ctx context.Context, path string, mode Mode, update bool, insecure, single, tests bool) error {
+	if mode == ModeModule {
+		// TODO: thread tests through to module resolution once the module
+		// path supports a separate test-only build list.
+		if err := g.getModule(ctx, path, insecure); err != nil {
+			return err
+		}
+		if single {
+			// don't build hints in single mode, same as the GOPATH path below
+			return nil
+		}
+		// Module mode doesn't build a package-import graph the way the
+		// GOPATH path does, so processPath's recursion isn't available here.
+		// Fold every module resolved into g.buildList so far into the hint
+		// instead, keyed by module path@version rather than a raw repo URL,
+		// so gitcache learns about modules resolved by earlier Get calls too
+		// (not just this one importPath).
+		hints := map[string][]string{}
+		for modPath, version := range g.buildList {
+			hints[modPath] = []string{modPath + "@" + version}
+		}
+		g.gitreq.SetHints(hints)
+		return nil
+	}
+
 	var stk ImportStack
-	if err := g.download(ctx, path, nil, &stk, update, insecure, single); err != nil {
+	if err := g.download(ctx, path, nil, &stk, update, insecure, single, tests); err != nil {
 		return err
 	}
 	if single {
 		// don't build hints in single mode
 		return nil
 	}
-	// after download, build a list of package path => dependency repo URLs for the gitcache hints
-	hints := map[string][]string{}
+	// After download, build a list of package path => dependency repo URLs
+	// for the gitcache hints. Fold these into g.hints rather than a local
+	// map: SetHints replaces the whole hint set, so a later Get call (e.g.
+	// each concrete match GetPatterns feeds through here) would otherwise
+	// clobber the hints an earlier call in the same Getter already built.
 	var processPath func(path string) []string
 	processPath = func(path string) []string {
 		urls := map[string]bool{}
 
 		p := g.packageCache[path]
 
+		if p == nil {
+			return nil
+		}
+
 		if p.Standard {
 			return nil
 		}
 
-		if p != nil {
-			for _, imp := range p.Imports {
+		for _, imp := range p.Imports {
+			urlsForImport := processPath(imp)
+			for _, url := range urlsForImport {
+				urls[url] = true
+			}
+		}
+		// Fold in test-only dependency repos too, but only when this Get
+		// call actually pulled test imports into the package cache - they
+		// aren't there otherwise, and processPath would just return nothing
+		// for them.
+		if tests {
+			var testImports []string
+			testImports = append(testImports, p.Internal.Build.TestImports...)
+			testImports = append(testImports, p.Internal.Build.XTestImports...)
+			for _, imp := range testImports {
 				urlsForImport := processPath(imp)
 				for _, url := range urlsForImport {
 					urls[url] = true
@@ -73,8 +133,14 @@ func (g *Getter) Get(ctx context.Context, path string, update bool, insecure, si
 
 		repoForThisPath, ok := g.repoPackages[path]
 		if ok {
-			urls[repoForThisPath.repo] = true
-		} else if p != nil {
+			if repoForThisPath.rev != "" {
+				// Encode the pinned revision into the hint so gitcache can
+				// fetch just that ref instead of the full history.
+				urls[repoForThisPath.root.repo+"@"+repoForThisPath.rev] = true
+			} else {
+				urls[repoForThisPath.root.repo] = true
+			}
+		} else {
 			root, _ := g.vcsFromDir(p.Dir, p.Internal.Build.SrcRoot)
 			// ignore error
 			if root != nil {
@@ -86,11 +152,11 @@ func (g *Getter) Get(ctx context.Context, path string, update bool, insecure, si
 		for url := range urls {
 			urlsArray = append(urlsArray, url)
 		}
-		hints[path] = urlsArray
+		g.hints[path] = urlsArray
 		return urlsArray
 	}
 	processPath(path)
-	g.gitreq.SetHints(hints)
+	g.gitreq.SetHints(g.hints)
 	return nil
 }
 